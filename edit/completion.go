@@ -0,0 +1,122 @@
+package edit
+
+import "strings"
+
+// completion is the state of an active completion session: the
+// candidates gathered for the word ed.dot was in when it was entered, and
+// which one (if any) is currently selected.
+type completion struct {
+	current    int
+	candidates []completionCandidate
+}
+
+// completionCandidate is one completion candidate.
+type completionCandidate struct {
+	source completionSource
+}
+
+// completionSource is the text acceptCompletion splices into the line.
+type completionSource struct {
+	text string
+}
+
+// startCompletion gathers candidates for the word ending at ed.dot and, if
+// there are any, removes that word and enters modeCompletion so it can be
+// replaced by whichever candidate is accepted. Candidates come entirely
+// from ed.runner.Names() and ed.isExternal -- the same two sources
+// goodFormHeadGeneric uses for highlighting -- so completion, like
+// highlighting, works for any Runner and isn't special-cased to elvish.
+func startCompletion(ed *Editor) {
+	begin := completionWordStart(ed.line, ed.dot)
+	word := ed.line[begin:ed.dot]
+
+	candidates := completionCandidates(ed, word)
+	if len(candidates) == 0 {
+		ed.pushTip("no completions for " + word)
+		return
+	}
+
+	ed.line = ed.line[:begin] + ed.line[ed.dot:]
+	ed.dot = begin
+	ed.completion = &completion{candidates: candidates}
+	ed.setMode(modeCompletion)
+}
+
+// completionCandidates collects every name visible through ed.runner and
+// ed.isExternal that starts with word.
+func completionCandidates(ed *Editor, word string) []completionCandidate {
+	var candidates []completionCandidate
+	add := func(text string) {
+		if word == "" || strings.HasPrefix(text, word) {
+			candidates = append(candidates, completionCandidate{completionSource{text}})
+		}
+	}
+	fns, vars := ed.runner.Names()
+	for _, name := range fns {
+		add(name)
+	}
+	for _, name := range vars {
+		add("$" + name)
+	}
+	for name := range ed.isExternal {
+		add(name)
+	}
+	return candidates
+}
+
+// completionWordStart finds the start of the whitespace-delimited word
+// ending at dot.
+func completionWordStart(line string, dot int) int {
+	i := dot
+	for i > 0 && !isCompletionBoundary(line[i-1]) {
+		i--
+	}
+	return i
+}
+
+func isCompletionBoundary(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n'
+}
+
+// completionNext selects the next candidate, wrapping around.
+func completionNext(ed *Editor) {
+	c := ed.completion
+	if c == nil || len(c.candidates) == 0 {
+		return
+	}
+	c.current = (c.current + 1) % len(c.candidates)
+}
+
+// completionPrev selects the previous candidate, wrapping around.
+func completionPrev(ed *Editor) {
+	c := ed.completion
+	if c == nil || len(c.candidates) == 0 {
+		return
+	}
+	c.current = (c.current - 1 + len(c.candidates)) % len(c.candidates)
+}
+
+// completionAccept is the modeCompletion Enter binding; it delegates to
+// acceptCompletion, which splices in the selected candidate, and then
+// switches back to modeInsert through setMode so mode hooks run.
+func completionAccept(ed *Editor) {
+	ed.acceptCompletion()
+	ed.setMode(modeInsert)
+}
+
+// completionAbort cancels completion, leaving the line as it is (minus
+// the word startCompletion already removed).
+func completionAbort(ed *Editor) {
+	ed.completion = nil
+	ed.setMode(modeInsert)
+}
+
+func init() {
+	keyBindings[modeInsert][Key{'I', Ctrl}] = BuiltinFn{"start-completion", startCompletion}
+	keyBindings[modeCompletion] = map[Key]BuiltinFn{
+		Key{'I', Ctrl}: BuiltinFn{"completion-next", completionNext},
+		Key{'P', Alt}:  BuiltinFn{"completion-prev", completionPrev},
+		Key{Enter, 0}:  BuiltinFn{"completion-accept", completionAccept},
+		Key{Escape, 0}: BuiltinFn{"completion-abort", completionAbort},
+	}
+}
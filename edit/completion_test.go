@@ -0,0 +1,51 @@
+package edit
+
+import "testing"
+
+func TestStartCompletionFiltersAndSplices(t *testing.T) {
+	ed := &Editor{editorState: editorState{line: "ec", dot: 2, mode: modeInsert},
+		runner: &fakeRunner{fns: []string{"echo", "ls"}}}
+	startCompletion(ed)
+	if ed.mode != modeCompletion {
+		t.Fatalf("mode = %v, want modeCompletion", ed.mode)
+	}
+	if ed.line != "" || ed.dot != 0 {
+		t.Fatalf("line/dot = %q/%d, want empty/0", ed.line, ed.dot)
+	}
+	if len(ed.completion.candidates) != 1 || ed.completion.candidates[0].source.text != "echo" {
+		t.Fatalf("candidates = %+v, want just echo", ed.completion.candidates)
+	}
+	completionAccept(ed)
+	if ed.line != "echo" || ed.mode != modeInsert {
+		t.Fatalf("after accept: line=%q mode=%v, want echo/modeInsert", ed.line, ed.mode)
+	}
+}
+
+func TestStartCompletionNoMatches(t *testing.T) {
+	ed := &Editor{editorState: editorState{line: "zz", dot: 2, mode: modeInsert},
+		runner: &fakeRunner{}}
+	startCompletion(ed)
+	if ed.mode != modeInsert {
+		t.Fatalf("mode = %v, want modeInsert (unchanged)", ed.mode)
+	}
+	if len(ed.tips) != 1 {
+		t.Fatalf("tips = %v, want one tip", ed.tips)
+	}
+}
+
+func TestCompletionNextPrevWrap(t *testing.T) {
+	c := &completion{candidates: []completionCandidate{{completionSource{"a"}}, {completionSource{"b"}}}}
+	ed := &Editor{editorState: editorState{completion: c}}
+	completionNext(ed)
+	if c.current != 1 {
+		t.Fatalf("current = %d, want 1", c.current)
+	}
+	completionNext(ed)
+	if c.current != 0 {
+		t.Fatalf("current = %d, want 0 (wrapped)", c.current)
+	}
+	completionPrev(ed)
+	if c.current != 1 {
+		t.Fatalf("current = %d, want 1 (wrapped back)", c.current)
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/elves/elvish/edit/jobs"
 	"github.com/elves/elvish/errutil"
 	"github.com/elves/elvish/eval"
 	"github.com/elves/elvish/logutil"
-	"github.com/elves/elvish/parse"
 	"github.com/elves/elvish/store"
 	"github.com/elves/elvish/sys"
 )
@@ -30,6 +30,8 @@ const (
 	modeCompletion
 	modeNavigation
 	modeHistory
+	modeHistSearch
+	modeNormal
 )
 
 type editorState struct {
@@ -45,6 +47,8 @@ type editorState struct {
 	completionLines       int
 	navigation            *navigation
 	history               history
+	histSearch            *histSearch
+	normal                normalState
 	isExternal            map[string]bool
 	// Used for builtins.
 	lastKey    Key
@@ -55,18 +59,66 @@ type history struct {
 	current int
 	prefix  string
 	line    string
+	// failed records whether the current match's command exited non-zero,
+	// so the writer can render it in a distinct style.
+	failed bool
+}
+
+// histEntry is one session-history record: the line itself, the directory
+// it was run in, and its exit status.
+type histEntry struct {
+	Line     string
+	Cwd      string
+	ExitCode int
+}
+
+// historyFilter restricts history navigation (Up/Down, and reverse-i-search)
+// to a subset of entries.
+type historyFilter struct {
+	// RestrictCwd, when set, only considers entries whose Cwd matches the
+	// editor's current working directory.
+	RestrictCwd bool
+	// HideFailed, when set, skips entries with a non-zero ExitCode.
+	HideFailed bool
+}
+
+// matches reports whether e passes the filter, given the shell's current
+// working directory.
+func (f historyFilter) matches(e histEntry, cwd string) bool {
+	if f.RestrictCwd && e.Cwd != cwd {
+		return false
+	}
+	if f.HideFailed && e.ExitCode != 0 {
+		return false
+	}
+	return true
 }
 
 // Editor keeps the status of the line editor.
 type Editor struct {
-	file      *os.File
-	writer    *writer
-	reader    *Reader
-	sigs      chan os.Signal
-	histories []string
-	store     *store.Store
+	file          *os.File
+	writer        *writer
+	reader        *Reader
+	sigs          chan os.Signal
+	histories     []histEntry
+	historyFilter historyFilter
+	store         *store.Store
+	runner        Runner
+	// evaler is set by NewElvishEditor and used only by elvish-specific
+	// extras (external-command detection) that go beyond
+	// what the Runner interface exposes. It is nil for editors built
+	// around a non-elvish Runner.
 	evaler    *eval.Evaler
 	cmdSeq    int
+	registers registers
+	modeHooks []func(bufferMode)
+	jobs      *jobs.Table
+	// foreground is the pgid of the job currently running in the
+	// foreground, or 0 if none; set by the evaluator before it blocks on a
+	// pipeline, and cleared when the pipeline finishes or is backgrounded.
+	foreground    int
+	jobStartHooks []func(*jobs.Job)
+	jobDoneHooks  []func(*jobs.Job)
 	editorState
 }
 
@@ -78,43 +130,62 @@ type LineRead struct {
 	Err  error
 }
 
-func (h *history) jump(i int, line string) {
+func (h *history) jump(i int, e histEntry) {
 	h.current = i
-	h.line = line
+	h.line = e.Line
+	h.failed = e.ExitCode != 0
 }
 
-func (ed *Editor) appendHistory(line string) {
-	ed.histories = append(ed.histories, line)
+// AppendHistory records a completed command in the session and persistent
+// history, along with the directory it ran in and its exit status. It may
+// be called more than once for the same command -- once when the line is
+// accepted, with a placeholder exit code, and again by the evaluator once
+// the pipeline has actually finished -- in which case the second call
+// updates the in-memory entry the first call made instead of adding a
+// second one, so Up/Down, modeHistory and reverse-i-search still see
+// exactly one ed.histories entry per command. A second call is recognized
+// only by its line matching the newest entry's, so the same line run twice
+// in a row also ends up as a single entry.
+func (ed *Editor) AppendHistory(line, cwd string, exitCode int) {
+	entry := histEntry{Line: line, Cwd: cwd, ExitCode: exitCode}
+	if n := len(ed.histories); n > 0 && ed.histories[n-1].Line == line {
+		ed.histories[n-1] = entry
+	} else {
+		ed.histories = append(ed.histories, entry)
+	}
 	if ed.store != nil {
-		ed.store.AddCmd(line)
+		ed.store.AddCmd(store.Cmd{Line: line, Cwd: cwd, ExitCode: exitCode})
 		// TODO(xiaq): Report possible error
 	}
 }
 
-func lastHistory(histories []string, upto int, prefix string) (int, string) {
+func lastHistory(histories []histEntry, upto int, prefix string, filter historyFilter, cwd string) (int, histEntry) {
 	for i := upto - 1; i >= 0; i-- {
-		if strings.HasPrefix(histories[i], prefix) {
-			return i, histories[i]
+		e := histories[i]
+		if strings.HasPrefix(e.Line, prefix) && filter.matches(e, cwd) {
+			return i, e
 		}
 	}
-	return -1, ""
+	return -1, histEntry{}
 }
 
-func firstHistory(histories []string, from int, prefix string) (int, string) {
+func firstHistory(histories []histEntry, from int, prefix string, filter historyFilter, cwd string) (int, histEntry) {
 	for i := from; i < len(histories); i++ {
-		if strings.HasPrefix(histories[i], prefix) {
-			return i, histories[i]
+		e := histories[i]
+		if strings.HasPrefix(e.Line, prefix) && filter.matches(e, cwd) {
+			return i, e
 		}
 	}
-	return -1, ""
+	return -1, histEntry{}
 }
 
 func (ed *Editor) prevHistory() bool {
+	cwd := currentCwd()
 	if ed.history.current > 0 {
 		// Session history
-		i, line := lastHistory(ed.histories, ed.history.current, ed.history.prefix)
+		i, e := lastHistory(ed.histories, ed.history.current, ed.history.prefix, ed.historyFilter, cwd)
 		if i >= 0 {
-			ed.history.jump(i, line)
+			ed.history.jump(i, e)
 			return true
 		}
 	}
@@ -122,9 +193,13 @@ func (ed *Editor) prevHistory() bool {
 	if ed.store != nil {
 		// Persistent history
 		upto := ed.cmdSeq + min(0, ed.history.current)
-		i, line, err := ed.store.LastCmd(upto, ed.history.prefix)
+		filterCwd := ""
+		if ed.historyFilter.RestrictCwd {
+			filterCwd = cwd
+		}
+		i, cmd, err := ed.store.LastCmd(upto, ed.history.prefix, filterCwd, ed.historyFilter.HideFailed)
 		if err == nil {
-			ed.history.jump(i-ed.cmdSeq, line)
+			ed.history.jump(i-ed.cmdSeq, histEntry{cmd.Line, cmd.Cwd, cmd.ExitCode})
 			return true
 		}
 	}
@@ -133,13 +208,18 @@ func (ed *Editor) prevHistory() bool {
 }
 
 func (ed *Editor) nextHistory() bool {
+	cwd := currentCwd()
 	if ed.store != nil {
 		// Persistent history
 		if ed.history.current < -1 {
 			from := ed.cmdSeq + ed.history.current + 1
-			i, line, err := ed.store.FirstCmd(from, ed.history.prefix)
+			filterCwd := ""
+			if ed.historyFilter.RestrictCwd {
+				filterCwd = cwd
+			}
+			i, cmd, err := ed.store.FirstCmd(from, ed.history.prefix, filterCwd, ed.historyFilter.HideFailed)
 			if err == nil {
-				ed.history.jump(i-ed.cmdSeq, line)
+				ed.history.jump(i-ed.cmdSeq, histEntry{cmd.Line, cmd.Cwd, cmd.ExitCode})
 				return true
 			}
 			// TODO(xiaq): Errors other than ErrNoMatchingCmd should be reported
@@ -147,16 +227,18 @@ func (ed *Editor) nextHistory() bool {
 	}
 
 	from := max(0, ed.history.current+1)
-	i, line := firstHistory(ed.histories, from, ed.history.prefix)
+	i, e := firstHistory(ed.histories, from, ed.history.prefix, ed.historyFilter, cwd)
 	if i >= 0 {
-		ed.history.jump(i, line)
+		ed.history.jump(i, e)
 		return true
 	}
 	return false
 }
 
-// NewEditor creates an Editor.
-func NewEditor(file *os.File, sigs chan os.Signal, ev *eval.Evaler, st *store.Store) *Editor {
+// NewEditor creates an Editor that edits and, via r, highlights and
+// completes against the given Runner. Callers wanting an elvish-backed
+// editor should use NewElvishEditor instead.
+func NewEditor(file *os.File, sigs chan os.Signal, r Runner, st *store.Store) *Editor {
 	seq := -1
 	if st != nil {
 		var err error
@@ -173,9 +255,19 @@ func NewEditor(file *os.File, sigs chan os.Signal, ev *eval.Evaler, st *store.St
 		reader: NewReader(file),
 		sigs:   sigs,
 		store:  st,
-		evaler: ev,
+		runner: r,
 		cmdSeq: seq,
+		jobs:   jobs.NewTable(),
 	}
+	return ed
+}
+
+// NewElvishEditor creates an Editor that runs ev as its Runner, preserving
+// the previous behavior of editors constructed directly around an
+// *eval.Evaler.
+func NewElvishEditor(file *os.File, sigs chan os.Signal, ev *eval.Evaler, st *store.Store) *Editor {
+	ed := NewEditor(file, sigs, NewElvishRunner(ev), st)
+	ed.evaler = ev
 	ev.Editor = ed
 	return ed
 }
@@ -184,6 +276,21 @@ func (ed *Editor) flash() {
 	// TODO implement fish-like flash effect
 }
 
+// RegisterAction registers f to be called, with the new mode, whenever the
+// editor switches bufferMode -- e.g. so a prompt can show a mode indicator
+// for vim-style normal/insert editing.
+func (ed *Editor) RegisterAction(f func(bufferMode)) {
+	ed.modeHooks = append(ed.modeHooks, f)
+}
+
+// setMode switches to m, running any hooks registered via RegisterAction.
+func (ed *Editor) setMode(m bufferMode) {
+	ed.mode = m
+	for _, f := range ed.modeHooks {
+		f(m)
+	}
+}
+
 func (ed *Editor) pushTip(more string) {
 	ed.tips = append(ed.tips, more)
 }
@@ -193,12 +300,12 @@ func (ed *Editor) refresh() error {
 	name := "[interacitve]"
 	src := ed.line
 	if ed.mode != modeCompletion {
-		n, _ /*err*/ := parse.Parse(src)
+		n, _ /*err*/ := ed.runner.Parse(src)
 		if n == nil {
 			ed.tokens = []Token{{ParserError, src, nil, ""}}
 		} else {
 			ed.tokens = tokenize(src, n)
-			_, err := ed.evaler.Compile(name, src, n)
+			err := ed.runner.Compile(name, src, n)
 			if err != nil {
 				if err, ok := err.(*errutil.ContextualError); ok {
 					ed.pushTip("compiler error highlighted")
@@ -218,6 +325,12 @@ func (ed *Editor) refresh() error {
 			}
 		}
 	}
+	if ed.mode == modeHistSearch {
+		ed.pushTip(ed.histSearch.statusLine())
+	}
+	if ed.mode == modeHistory && ed.history.failed {
+		ed.pushTip("history: this command exited with a non-zero status")
+	}
 	return ed.writer.refresh(&ed.editorState)
 }
 
@@ -293,6 +406,11 @@ func (ed *Editor) startReadLine() error {
 	// mechanism.
 	fmt.Fprintf(ed.file, "\033[?7h%s%*s\r \r\033[?7l", lackEOL, width-WcWidth(lackEOLRune), "")
 
+	// Turn on bracketed paste, so pasted text arrives wrapped in
+	// \033[200~ ... \033[201~ and the Reader can deliver it as a single
+	// Paste event instead of a flood of keystrokes.
+	ed.file.WriteString("\033[?2004h")
+
 	return nil
 }
 
@@ -303,6 +421,7 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 	ed.tips = nil
 	ed.completion = nil
 	ed.navigation = nil
+	ed.histSearch = nil
 	ed.dot = len(ed.line)
 	// TODO Perhaps make it optional to NOT clear the rprompt
 	ed.rprompt = ""
@@ -312,7 +431,8 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 	// ed.reader.Stop()
 	ed.reader.Quit()
 
-	// turn on autowrap
+	// turn off bracketed paste and turn on autowrap
+	ed.file.WriteString("\033[?2004l")
 	ed.file.WriteString("\033[?7h")
 
 	// restore termios
@@ -331,7 +451,9 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 func (ed *Editor) ReadLine(prompt, rprompt func() string) (lr LineRead) {
 	ed.editorState = editorState{active: true}
 	isExternalCh := make(chan map[string]bool, 1)
-	go getIsExternal(ed.evaler, isExternalCh)
+	if ed.evaler != nil {
+		go getIsExternal(ed.evaler, isExternalCh)
+	}
 
 	ed.writer.resetOldBuf()
 	ones := ed.reader.Chan()
@@ -374,8 +496,10 @@ MainLoop:
 				goto MainLoop
 			case syscall.SIGWINCH:
 				continue MainLoop
+			case syscall.SIGTSTP:
+				ed.suspendForeground()
 			case syscall.SIGCHLD:
-				// ignore
+				ed.reapChildren()
 			default:
 				ed.pushTip(fmt.Sprintf("ignored signal %s", sig))
 			}
@@ -392,6 +516,15 @@ MainLoop:
 				continue
 			}
 
+			if or.Paste != "" {
+				// Bracketed paste: insert verbatim, without running it
+				// through key bindings. This is what keeps auto-indent,
+				// accidental execution on embedded newlines, and completion
+				// menus from reacting to pasted multi-line snippets.
+				ed.insertAtDot(or.Paste)
+				continue
+			}
+
 			k := or.Key
 		lookupKey:
 			keyBinding, ok := keyBindings[ed.mode]
@@ -421,10 +554,17 @@ MainLoop:
 				goto lookupKey
 			case exitReadLine:
 				lr = act.returnValue
-				if lr.EOF == false && lr.Err == nil && lr.Line != "" {
-					ed.appendHistory(lr.Line)
+				if !lr.EOF && lr.Err == nil && lr.Line != "" {
+					// Record into session/persistent history right away,
+					// with a placeholder exit code of 0: the real status
+					// isn't known until the caller has actually run the
+					// line. Callers that want accurate ExitCode/Cwd
+					// tracking should call AppendHistory again themselves
+					// once the pipeline finishes -- AppendHistory updates
+					// this same placeholder entry in place rather than
+					// adding a second one for the one command.
+					ed.AppendHistory(lr.Line, currentCwd(), 0)
 				}
-
 				return lr
 			}
 		}
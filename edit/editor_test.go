@@ -0,0 +1,26 @@
+package edit
+
+import "testing"
+
+func TestAppendHistoryUpdatesPlaceholderInPlace(t *testing.T) {
+	ed := &Editor{}
+	ed.AppendHistory("echo hi", "/tmp", 0)
+	ed.AppendHistory("echo hi", "/tmp", 1)
+
+	if len(ed.histories) != 1 {
+		t.Fatalf("len(ed.histories) = %d, want 1", len(ed.histories))
+	}
+	if got := ed.histories[0]; got.ExitCode != 1 {
+		t.Fatalf("ed.histories[0] = %+v, want ExitCode 1", got)
+	}
+}
+
+func TestAppendHistoryAppendsForDifferentLines(t *testing.T) {
+	ed := &Editor{}
+	ed.AppendHistory("echo one", "/tmp", 0)
+	ed.AppendHistory("echo two", "/tmp", 0)
+
+	if len(ed.histories) != 2 {
+		t.Fatalf("len(ed.histories) = %d, want 2", len(ed.histories))
+	}
+}
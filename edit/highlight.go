@@ -17,37 +17,59 @@ func doHighlight(n parse.Node, ed *Editor) {
 	s.EmitAll(n)
 }
 
+// goodFormHead reports whether head looks like a valid command, routing the
+// check through ed.runner so that it works for any Runner, not just elvish.
+// The elvish runner keeps its full rules (special forms, namespaces, the
+// e: escape); other runners get a generic fns/vars + external-command check.
 func goodFormHead(head string, ed *Editor) bool {
-	if eval.IsBuiltinSpecial[head] {
+	if _, ok := ed.runner.(*elvishRunner); ok && eval.IsBuiltinSpecial[head] {
+		// Preserve the original elvish ordering: a special form is good
+		// regardless of what util.DontSearch says about it.
 		return true
-	} else if util.DontSearch(head) {
+	}
+	if util.DontSearch(head) {
 		// XXX don't stat twice
 		return util.IsExecutable(head) || isDir(head)
-	} else {
-		ev := ed.evaler
-		explode, ns, name := eval.ParseVariable(head)
-		if !explode {
-			switch ns {
-			case "":
-				if ev.Builtin.Names[eval.FnPrefix+name] != nil || ev.Global.Names[eval.FnPrefix+name] != nil {
-					return true
-				}
-			case "e":
-				if ed.isExternal[name] {
-					return true
-				}
-			default:
-				mod := ev.Global.Uses[ns]
-				if mod == nil {
-					mod = ev.Builtin.Uses[ns]
-				}
-				if mod != nil && mod[eval.FnPrefix+name] != nil {
-					return true
-				}
+	}
+	if er, ok := ed.runner.(*elvishRunner); ok {
+		return goodFormHeadElvish(head, ed, er.ev)
+	}
+	return goodFormHeadGeneric(head, ed)
+}
+
+func goodFormHeadElvish(head string, ed *Editor, ev *eval.Evaler) bool {
+	explode, ns, name := eval.ParseVariable(head)
+	if !explode {
+		switch ns {
+		case "":
+			if ev.Builtin.Names[eval.FnPrefix+name] != nil || ev.Global.Names[eval.FnPrefix+name] != nil {
+				return true
+			}
+		case "e":
+			if ed.isExternal[name] {
+				return true
 			}
+		default:
+			mod := ev.Global.Uses[ns]
+			if mod == nil {
+				mod = ev.Builtin.Uses[ns]
+			}
+			if mod != nil && mod[eval.FnPrefix+name] != nil {
+				return true
+			}
+		}
+	}
+	return ed.isExternal[head]
+}
+
+func goodFormHeadGeneric(head string, ed *Editor) bool {
+	fns, _ := ed.runner.Names()
+	for _, name := range fns {
+		if name == head {
+			return true
 		}
-		return ed.isExternal[head]
 	}
+	return ed.isExternal[head]
 }
 
 func isDir(fname string) bool {
@@ -0,0 +1,39 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/parse"
+)
+
+// fakeRunner is a minimal non-elvish Runner, used to exercise
+// goodFormHeadGeneric without needing a real evaluator.
+type fakeRunner struct {
+	fns []string
+}
+
+func (r *fakeRunner) Parse(src string) (parse.Node, error)         { return nil, nil }
+func (r *fakeRunner) Compile(name, src string, n parse.Node) error { return nil }
+func (r *fakeRunner) Names() (fns, vars []string)                  { return r.fns, nil }
+
+func TestGoodFormHeadGenericKnownName(t *testing.T) {
+	ed := &Editor{runner: &fakeRunner{fns: []string{"foo"}}}
+	if !goodFormHead("foo", ed) {
+		t.Fatalf("goodFormHead(%q) = false, want true for a name the runner reports", "foo")
+	}
+}
+
+func TestGoodFormHeadGenericExternal(t *testing.T) {
+	ed := &Editor{runner: &fakeRunner{}}
+	ed.isExternal = map[string]bool{"ls": true}
+	if !goodFormHead("ls", ed) {
+		t.Fatalf("goodFormHead(%q) = false, want true for a known external command", "ls")
+	}
+}
+
+func TestGoodFormHeadGenericUnknown(t *testing.T) {
+	ed := &Editor{runner: &fakeRunner{}}
+	if goodFormHead("nope", ed) {
+		t.Fatalf("goodFormHead(%q) = true, want false", "nope")
+	}
+}
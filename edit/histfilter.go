@@ -0,0 +1,48 @@
+package edit
+
+import "os"
+
+// currentCwd returns the working directory to tag new history entries with
+// and to match historyFilter.RestrictCwd against. Errors are swallowed; an
+// unknown cwd just means cwd-restricted filtering matches nothing.
+func currentCwd() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return wd
+}
+
+// toggleHistRestrictCwd flips whether Up/Down and reverse-i-search only
+// consider history entries run in the current directory.
+func toggleHistRestrictCwd(ed *Editor) {
+	ed.historyFilter.RestrictCwd = !ed.historyFilter.RestrictCwd
+	if ed.historyFilter.RestrictCwd {
+		ed.pushTip("history: restricted to " + currentCwd())
+	} else {
+		ed.pushTip("history: showing all directories")
+	}
+}
+
+// toggleHistHideFailed flips whether Up/Down and reverse-i-search skip
+// commands that exited non-zero.
+func toggleHistHideFailed(ed *Editor) {
+	ed.historyFilter.HideFailed = !ed.historyFilter.HideFailed
+	if ed.historyFilter.HideFailed {
+		ed.pushTip("history: hiding failed commands")
+	} else {
+		ed.pushTip("history: showing failed commands")
+	}
+}
+
+func init() {
+	toggleCwd := BuiltinFn{"toggle-history-restrict-cwd", toggleHistRestrictCwd}
+	toggleFailed := BuiltinFn{"toggle-history-hide-failed", toggleHistHideFailed}
+	for _, mode := range []bufferMode{modeHistory, modeHistSearch} {
+		if keyBindings[mode] == nil {
+			keyBindings[mode] = map[Key]BuiltinFn{}
+		}
+		keyBindings[mode][Key{'D', Alt}] = toggleCwd
+		keyBindings[mode][Key{'F', Alt}] = toggleFailed
+	}
+}
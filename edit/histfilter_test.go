@@ -0,0 +1,24 @@
+package edit
+
+import "testing"
+
+func TestHistoryFilterMatches(t *testing.T) {
+	cases := []struct {
+		filter historyFilter
+		entry  histEntry
+		cwd    string
+		want   bool
+	}{
+		{historyFilter{}, histEntry{Cwd: "/a", ExitCode: 1}, "/b", true},
+		{historyFilter{RestrictCwd: true}, histEntry{Cwd: "/a"}, "/a", true},
+		{historyFilter{RestrictCwd: true}, histEntry{Cwd: "/a"}, "/b", false},
+		{historyFilter{HideFailed: true}, histEntry{ExitCode: 0}, "/a", true},
+		{historyFilter{HideFailed: true}, histEntry{ExitCode: 1}, "/a", false},
+		{historyFilter{RestrictCwd: true, HideFailed: true}, histEntry{Cwd: "/a", ExitCode: 1}, "/a", false},
+	}
+	for _, c := range cases {
+		if got := c.filter.matches(c.entry, c.cwd); got != c.want {
+			t.Errorf("%+v.matches(%+v, %q) = %v, want %v", c.filter, c.entry, c.cwd, got, c.want)
+		}
+	}
+}
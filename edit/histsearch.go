@@ -0,0 +1,247 @@
+package edit
+
+import "strings"
+
+// histSearch is the state of an incremental reverse history search, entered
+// with Ctrl-R from modeInsert.
+type histSearch struct {
+	// The line and dot to restore if the search is aborted.
+	savedLine string
+	savedDot  int
+	// The query typed so far.
+	query string
+	// Index into ed.histories of the current match, or -1 if the current
+	// match comes from the persistent store. currentSeq is then the
+	// corresponding store sequence number.
+	current    int
+	currentSeq int
+	// The current match, and the [begin, end) range within it that the
+	// query matched, for highlighting.
+	line       string
+	begin, end int
+	// failed records whether the current match's command exited non-zero,
+	// so statusLine can render it in a distinct style.
+	failed bool
+}
+
+func newHistSearch(ed *Editor) *histSearch {
+	return &histSearch{
+		savedLine:  ed.line,
+		savedDot:   ed.dot,
+		current:    len(ed.histories),
+		currentSeq: ed.cmdSeq + 1,
+	}
+}
+
+// sessionSearchBackward looks, starting just before index from, for the
+// nearest older entry in histories containing query as a substring and
+// passing filter. It is a free function, rather than a method, so the
+// matching logic can be unit tested without a live Editor.
+func sessionSearchBackward(histories []histEntry, from int, query string, filter historyFilter, cwd string) (i int, e histEntry, idx int, ok bool) {
+	for j := from - 1; j >= 0; j-- {
+		c := histories[j]
+		if !filter.matches(c, cwd) {
+			continue
+		}
+		if k := strings.Index(c.Line, query); k >= 0 {
+			return j, c, k, true
+		}
+	}
+	return 0, histEntry{}, 0, false
+}
+
+// sessionSearchForward is sessionSearchBackward's mirror image: it looks,
+// starting just after index from, for the nearest newer matching entry.
+// from may be -1 to search the whole slice from the beginning.
+func sessionSearchForward(histories []histEntry, from int, query string, filter historyFilter, cwd string) (i int, e histEntry, idx int, ok bool) {
+	for j := from + 1; j < len(histories); j++ {
+		c := histories[j]
+		if !filter.matches(c, cwd) {
+			continue
+		}
+		if k := strings.Index(c.Line, query); k >= 0 {
+			return j, c, k, true
+		}
+	}
+	return 0, histEntry{}, 0, false
+}
+
+// searchBackward looks for the next older match, in the in-memory session
+// history first and then, once that is exhausted, in the persistent store.
+func (hs *histSearch) searchBackward(ed *Editor) bool {
+	cwd := currentCwd()
+	if hs.current > 0 {
+		if i, e, idx, ok := sessionSearchBackward(ed.histories, hs.current, hs.query, ed.historyFilter, cwd); ok {
+			hs.current = i
+			hs.setMatch(e.Line, idx, len(hs.query), e.ExitCode != 0)
+			return true
+		}
+	}
+	if ed.store != nil {
+		filterCwd := ""
+		if ed.historyFilter.RestrictCwd {
+			filterCwd = cwd
+		}
+		upto := hs.currentSeq
+		for {
+			i, cmd, err := ed.store.LastCmd(upto, "", filterCwd, ed.historyFilter.HideFailed)
+			if err != nil {
+				break
+			}
+			upto = i
+			if idx := strings.Index(cmd.Line, hs.query); idx >= 0 {
+				hs.current = -1
+				hs.currentSeq = i
+				hs.setMatch(cmd.Line, idx, len(hs.query), cmd.ExitCode != 0)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchForward looks for the next newer match: first in the persistent
+// store (if the current match came from there), then in the in-memory
+// session history.
+func (hs *histSearch) searchForward(ed *Editor) bool {
+	cwd := currentCwd()
+	if hs.current < 0 && ed.store != nil {
+		filterCwd := ""
+		if ed.historyFilter.RestrictCwd {
+			filterCwd = cwd
+		}
+		from := hs.currentSeq + 1
+		for {
+			i, cmd, err := ed.store.FirstCmd(from, "", filterCwd, ed.historyFilter.HideFailed)
+			if err != nil {
+				break
+			}
+			from = i + 1
+			if idx := strings.Index(cmd.Line, hs.query); idx >= 0 {
+				hs.currentSeq = i
+				hs.setMatch(cmd.Line, idx, len(hs.query), cmd.ExitCode != 0)
+				return true
+			}
+		}
+		// The store is exhausted moving forward; continue into the
+		// session history from the beginning.
+		hs.current = -1
+	}
+	if i, e, idx, ok := sessionSearchForward(ed.histories, hs.current, hs.query, ed.historyFilter, cwd); ok {
+		hs.current = i
+		hs.setMatch(e.Line, idx, len(hs.query), e.ExitCode != 0)
+		return true
+	}
+	return false
+}
+
+func (hs *histSearch) setMatch(line string, begin, length int, failed bool) {
+	hs.line = line
+	hs.begin = begin
+	hs.end = begin + length
+	hs.failed = failed
+}
+
+// statusLine renders the "(reverse-i-search)`query': match" line shown in
+// the tips slot while searching, with the matched segment of the line
+// picked out in reverse video.
+func (hs *histSearch) statusLine() string {
+	prefix := "(reverse-i-search)`" + hs.query + "': "
+	if hs.line == "" {
+		return prefix
+	}
+	line := prefix + hs.line[:hs.begin] + "\033[7m" + hs.line[hs.begin:hs.end] + "\033[m" + hs.line[hs.end:]
+	if hs.failed {
+		line = "\033[31m" + line + "\033[m"
+	}
+	return line
+}
+
+func startHistSearch(ed *Editor) {
+	ed.histSearch = newHistSearch(ed)
+	ed.setMode(modeHistSearch)
+}
+
+func histSearchBackward(ed *Editor) {
+	hs := ed.histSearch
+	if hs == nil {
+		return
+	}
+	if !hs.searchBackward(ed) {
+		ed.pushTip("no earlier matches for " + hs.query)
+	}
+}
+
+func histSearchForward(ed *Editor) {
+	hs := ed.histSearch
+	if hs == nil {
+		return
+	}
+	if !hs.searchForward(ed) {
+		ed.pushTip("no later matches for " + hs.query)
+	}
+}
+
+func histSearchAccept(ed *Editor) {
+	hs := ed.histSearch
+	if hs != nil && hs.line != "" {
+		ed.line = hs.line
+		ed.dot = len(ed.line)
+	}
+	ed.histSearch = nil
+	ed.setMode(modeInsert)
+}
+
+func histSearchAbort(ed *Editor) {
+	hs := ed.histSearch
+	if hs != nil {
+		ed.line = hs.savedLine
+		ed.dot = hs.savedDot
+	}
+	ed.histSearch = nil
+	ed.setMode(modeInsert)
+}
+
+func histSearchDefault(ed *Editor) {
+	hs := ed.histSearch
+	if hs == nil {
+		return
+	}
+	k := ed.lastKey
+	switch {
+	case k.Mod == 0 && k.Rune == Backspace:
+		if len(hs.query) > 0 {
+			hs.query = hs.query[:len(hs.query)-1]
+			hs.current = len(ed.histories)
+			hs.searchBackward(ed)
+		}
+	case k.Mod == 0 && k.Rune > 0:
+		hs.query += string(k.Rune)
+		hs.current = len(ed.histories)
+		if !hs.searchBackward(ed) {
+			ed.pushTip("no matches for " + hs.query)
+		}
+	default:
+		ed.pushTip("Unbound: " + k.String())
+	}
+}
+
+func init() {
+	// Merge into keyBindings[modeHistSearch] rather than assigning a new
+	// map wholesale: histfilter.go's init also populates this mode's
+	// bindings, and Go does not guarantee which init() runs first, so
+	// whichever ran second would otherwise clobber the other's entries.
+	if keyBindings[modeHistSearch] == nil {
+		keyBindings[modeHistSearch] = map[Key]BuiltinFn{}
+	}
+	m := keyBindings[modeHistSearch]
+	m[Key{'R', Ctrl}] = BuiltinFn{"hist-search-backward", histSearchBackward}
+	m[Key{'S', Ctrl}] = BuiltinFn{"hist-search-forward", histSearchForward}
+	m[Key{'R', Alt}] = BuiltinFn{"hist-search-forward", histSearchForward}
+	m[Key{'G', Ctrl}] = BuiltinFn{"hist-search-abort", histSearchAbort}
+	m[Key{Escape, 0}] = BuiltinFn{"hist-search-abort", histSearchAbort}
+	m[Key{Enter, 0}] = BuiltinFn{"hist-search-accept", histSearchAccept}
+	m[DefaultBinding] = BuiltinFn{"hist-search-default", histSearchDefault}
+
+	keyBindings[modeInsert][Key{'R', Ctrl}] = BuiltinFn{"start-hist-search", startHistSearch}
+}
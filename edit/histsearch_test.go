@@ -0,0 +1,53 @@
+package edit
+
+import "testing"
+
+func TestSessionSearchBackward(t *testing.T) {
+	histories := []histEntry{
+		{Line: "echo one", ExitCode: 0},
+		{Line: "echo two", ExitCode: 0},
+		{Line: "ls three", ExitCode: 0},
+	}
+	i, e, idx, ok := sessionSearchBackward(histories, 3, "echo", historyFilter{}, "")
+	if !ok || i != 1 || e.Line != "echo two" || idx != 0 {
+		t.Fatalf("got (%d, %+v, %d, %v), want (1, echo two, 0, true)", i, e, idx, ok)
+	}
+	i, e, idx, ok = sessionSearchBackward(histories, 1, "echo", historyFilter{}, "")
+	if !ok || i != 0 || e.Line != "echo one" || idx != 0 {
+		t.Fatalf("got (%d, %+v, %d, %v), want (0, echo one, 0, true)", i, e, idx, ok)
+	}
+	if _, _, _, ok := sessionSearchBackward(histories, 0, "echo", historyFilter{}, ""); ok {
+		t.Fatalf("expected no match searching backward from index 0")
+	}
+}
+
+func TestSessionSearchForward(t *testing.T) {
+	histories := []histEntry{
+		{Line: "echo one", ExitCode: 0},
+		{Line: "ls two", ExitCode: 0},
+		{Line: "echo three", ExitCode: 0},
+	}
+	i, e, idx, ok := sessionSearchForward(histories, -1, "echo", historyFilter{}, "")
+	if !ok || i != 0 || e.Line != "echo one" || idx != 0 {
+		t.Fatalf("got (%d, %+v, %d, %v), want (0, echo one, 0, true)", i, e, idx, ok)
+	}
+	i, e, idx, ok = sessionSearchForward(histories, 0, "echo", historyFilter{}, "")
+	if !ok || i != 2 || e.Line != "echo three" || idx != 0 {
+		t.Fatalf("got (%d, %+v, %d, %v), want (2, echo three, 0, true)", i, e, idx, ok)
+	}
+	if _, _, _, ok := sessionSearchForward(histories, 2, "echo", historyFilter{}, ""); ok {
+		t.Fatalf("expected no match searching forward past the last entry")
+	}
+}
+
+func TestSessionSearchHonorsFilter(t *testing.T) {
+	histories := []histEntry{
+		{Line: "build", Cwd: "/b", ExitCode: 0},
+		{Line: "build", Cwd: "/a", ExitCode: 1},
+	}
+	filter := historyFilter{HideFailed: true}
+	i, _, _, ok := sessionSearchBackward(histories, 2, "build", filter, "")
+	if !ok || i != 0 {
+		t.Fatalf("expected HideFailed to skip the failed entry, got i=%d ok=%v", i, ok)
+	}
+}
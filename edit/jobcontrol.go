@@ -0,0 +1,148 @@
+package edit
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/elves/elvish/edit/jobs"
+)
+
+// StartJob registers a newly started pipeline running in pgid as the
+// foreground job, hands it the controlling terminal, and returns it, so
+// that Ctrl-Z and SIGCHLD handling in ReadLine can track it. It is meant to
+// be called by the evaluator right before it blocks waiting for the
+// pipeline. A non-nil error means the terminal could not be handed over
+// (e.g. the pgid has already exited); the job is still tracked.
+func (ed *Editor) StartJob(pgid int, command string) (*jobs.Job, error) {
+	j := ed.jobs.Add(pgid, command)
+	ed.foreground = pgid
+	ed.fireJobStart(j)
+	return j, jobs.Foreground(int(ed.file.Fd()), pgid)
+}
+
+// WaitForJob blocks until the foreground job running in pgid stops or
+// exits, handling SIGTSTP and SIGCHLD as they arrive in the meantime. It
+// is meant to be called by the evaluator right after StartJob, in place
+// of a bare wait4: ReadLine has already returned the accepted line by the
+// time a pipeline is actually running, so its own select loop -- the
+// only other place these signals are handled -- isn't being polled, and
+// a Ctrl-Z sent to the foreground pgid would otherwise go unnoticed
+// until the next ReadLine call, by which point the job may already have
+// moved on.
+func (ed *Editor) WaitForJob(pgid int) {
+	for ed.foreground == pgid {
+		switch <-ed.sigs {
+		case syscall.SIGTSTP:
+			ed.suspendForeground()
+		case syscall.SIGCHLD:
+			ed.reapChildren()
+		}
+	}
+}
+
+// Jobs lists all tracked jobs, in ascending id order.
+func (ed *Editor) Jobs() []*jobs.Job {
+	return ed.jobs.List()
+}
+
+// Fg brings job id into the foreground, resuming it if it was stopped.
+func (ed *Editor) Fg(id int) error {
+	j, ok := ed.jobs.Get(id)
+	if !ok {
+		return fmt.Errorf("no such job: %%%d", id)
+	}
+	ed.foreground = j.Pgid
+	ed.jobs.SetState(j.Pgid, jobs.Running)
+	return jobs.Foreground(int(ed.file.Fd()), j.Pgid)
+}
+
+// Bg resumes a stopped job in the background.
+func (ed *Editor) Bg(id int) error {
+	j, ok := ed.jobs.Get(id)
+	if !ok {
+		return fmt.Errorf("no such job: %%%d", id)
+	}
+	ed.jobs.SetState(j.Pgid, jobs.Running)
+	return jobs.Background(j.Pgid)
+}
+
+// RegisterJobStartHook registers f to be called whenever a new job starts.
+func (ed *Editor) RegisterJobStartHook(f func(*jobs.Job)) {
+	ed.jobStartHooks = append(ed.jobStartHooks, f)
+}
+
+// RegisterJobDoneHook registers f to be called whenever a tracked job
+// finishes.
+func (ed *Editor) RegisterJobDoneHook(f func(*jobs.Job)) {
+	ed.jobDoneHooks = append(ed.jobDoneHooks, f)
+}
+
+func (ed *Editor) fireJobStart(j *jobs.Job) {
+	for _, f := range ed.jobStartHooks {
+		f(j)
+	}
+}
+
+func (ed *Editor) fireJobDone(j *jobs.Job) {
+	for _, f := range ed.jobDoneHooks {
+		f(j)
+	}
+}
+
+// suspendForeground handles a SIGTSTP delivered while editing: it stops the
+// foreground job's process group, takes the terminal back for the editor,
+// and leaves a tip behind like bash's "[1]+ Stopped".
+func (ed *Editor) suspendForeground() {
+	if ed.foreground == 0 {
+		return
+	}
+	pgid := ed.foreground
+	jobs.Signal(pgid, syscall.SIGSTOP)
+	jobs.Foreground(int(ed.file.Fd()), syscall.Getpgrp())
+	ed.foreground = 0
+	if j, ok := ed.jobs.SetState(pgid, jobs.Stopped); ok {
+		ed.pushTip(fmt.Sprintf("[%d]+ Stopped  %s", j.ID, j.Command))
+	}
+}
+
+// reapChildren drains exited and stopped children reported via SIGCHLD,
+// updating the job table and leaving a tip when a background job changes
+// state.
+func (ed *Editor) reapChildren() {
+	var ws syscall.WaitStatus
+	for {
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG|syscall.WUNTRACED, nil)
+		if err != nil || pid <= 0 {
+			return
+		}
+		switch {
+		case ws.Stopped():
+			// pid is still alive (merely stopped), so Getpgid still works.
+			pgid, err := syscall.Getpgid(pid)
+			if err != nil {
+				continue
+			}
+			if j, ok := ed.jobs.SetState(pgid, jobs.Stopped); ok {
+				if ed.foreground == pgid {
+					ed.foreground = 0
+				}
+				ed.pushTip(fmt.Sprintf("[%d]+ Stopped  %s", j.ID, j.Command))
+			}
+		case ws.Exited(), ws.Signaled():
+			// pid has already been reaped, so syscall.Getpgid(pid) would
+			// fail with ESRCH: the kernel drops the process table entry
+			// as soon as Wait4 returns its status. Every job tracked here
+			// is a single process group started with its own pid as the
+			// pgid (see StartJob), so pid itself is the key to look it up
+			// by -- no Getpgid call needed.
+			if j, ok := ed.jobs.SetState(pid, jobs.Done); ok {
+				ed.jobs.Remove(j.ID)
+				if ed.foreground == pid {
+					ed.foreground = 0
+				}
+				ed.pushTip(fmt.Sprintf("[%d]+ Done  %s", j.ID, j.Command))
+				ed.fireJobDone(j)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package edit
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/edit/jobs"
+)
+
+func TestWaitForJobReturnsWhenJobExits(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 0.2; exit 0")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		t.Skipf("could not start test child: %v", err)
+	}
+	pgid := cmd.Process.Pid
+
+	ed := &Editor{sigs: sigs, jobs: jobs.NewTable()}
+	ed.jobs.Add(pgid, "exit 0")
+	ed.foreground = pgid
+
+	done := make(chan struct{})
+	go func() {
+		ed.WaitForJob(pgid)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("WaitForJob did not return after the job exited")
+	}
+	if ed.foreground != 0 {
+		t.Fatalf("ed.foreground = %d, want 0", ed.foreground)
+	}
+}
@@ -0,0 +1,31 @@
+// +build !windows
+// +build !plan9
+
+package jobs
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Foreground gives the controlling terminal fd to pgid and sends it
+// SIGCONT, resuming a stopped or backgrounded job in the foreground.
+func Foreground(fd int, pgid int) error {
+	p := int32(pgid)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&p)))
+	if errno != 0 {
+		return errno
+	}
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// Background sends pgid SIGCONT without taking back the terminal, resuming
+// a stopped job in the background.
+func Background(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// Signal sends sig to every process in pgid's process group.
+func Signal(pgid int, sig syscall.Signal) error {
+	return syscall.Kill(-pgid, sig)
+}
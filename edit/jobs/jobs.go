@@ -0,0 +1,123 @@
+// Package jobs tracks the child processes started from an interactive
+// session, so the line editor can implement job control (fg, bg, and
+// notifications when background jobs change state).
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the run state of a Job.
+type State int
+
+// Possible values of State.
+const (
+	Running State = iota
+	Stopped
+	Done
+)
+
+func (s State) String() string {
+	switch s {
+	case Running:
+		return "Running"
+	case Stopped:
+		return "Stopped"
+	case Done:
+		return "Done"
+	default:
+		return "Unknown"
+	}
+}
+
+// Job is a single tracked pipeline.
+type Job struct {
+	ID      int
+	Pgid    int
+	State   State
+	Start   time.Time
+	Command string
+}
+
+// Table tracks the jobs of a session, keyed by both an elvish-visible id
+// (1, 2, 3, ...) and the OS process group id used to control them.
+type Table struct {
+	mu     sync.Mutex
+	jobs   map[int]*Job
+	nextID int
+}
+
+// NewTable creates an empty job table.
+func NewTable() *Table {
+	return &Table{jobs: make(map[int]*Job)}
+}
+
+// Add registers a new running job with the given process group and command
+// line, and returns it.
+func (t *Table) Add(pgid int, command string) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	j := &Job{ID: t.nextID, Pgid: pgid, State: Running, Start: time.Now(), Command: command}
+	t.jobs[j.ID] = j
+	return j
+}
+
+// Get looks up a job by its elvish-visible id.
+func (t *Table) Get(id int) (*Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j, ok := t.jobs[id]
+	return j, ok
+}
+
+// ByPgid looks up a job by its process group id.
+func (t *Table) ByPgid(pgid int) (*Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, j := range t.jobs {
+		if j.Pgid == pgid {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+// List returns all jobs, in ascending id order.
+func (t *Table) List() []*Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]*Job, 0, len(t.jobs))
+	for _, j := range t.jobs {
+		list = append(list, j)
+	}
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && list[j-1].ID > list[j].ID; j-- {
+			list[j-1], list[j] = list[j], list[j-1]
+		}
+	}
+	return list
+}
+
+// SetState updates the state of the job with the given process group id,
+// returning it. ok is false if no such job is tracked.
+func (t *Table) SetState(pgid int, s State) (j *Job, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, job := range t.jobs {
+		if job.Pgid == pgid {
+			job.State = s
+			return job, true
+		}
+	}
+	return nil, false
+}
+
+// Remove drops a job from the table, e.g. once it has been reported as
+// Done.
+func (t *Table) Remove(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.jobs, id)
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import "testing"
+
+func TestTableListIsOrderedById(t *testing.T) {
+	tb := NewTable()
+	tb.Add(100, "one")
+	tb.Add(200, "two")
+	tb.Add(300, "three")
+
+	list := tb.List()
+	if len(list) != 3 {
+		t.Fatalf("List() returned %d jobs, want 3", len(list))
+	}
+	for i, j := range list {
+		if j.ID != i+1 {
+			t.Fatalf("List()[%d].ID = %d, want %d", i, j.ID, i+1)
+		}
+	}
+}
+
+func TestTableSetStateAndRemove(t *testing.T) {
+	tb := NewTable()
+	j := tb.Add(42, "sleep 100")
+
+	if got, ok := tb.SetState(42, Stopped); !ok || got.State != Stopped {
+		t.Fatalf("SetState(42, Stopped) = (%+v, %v), want Stopped job", got, ok)
+	}
+	if got, ok := tb.ByPgid(42); !ok || got.ID != j.ID {
+		t.Fatalf("ByPgid(42) = (%+v, %v), want job %d", got, ok, j.ID)
+	}
+
+	tb.Remove(j.ID)
+	if _, ok := tb.Get(j.ID); ok {
+		t.Fatalf("job %d still present after Remove", j.ID)
+	}
+}
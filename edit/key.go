@@ -0,0 +1,68 @@
+package edit
+
+// Mod is a bitmask of the modifier keys held down together with a key.
+type Mod int
+
+const (
+	Ctrl Mod = 1 << iota
+	Alt
+)
+
+// Special, non-printable keys are represented as negative rune values so
+// they can share Key's Rune field with ordinary printable runes.
+const (
+	Escape rune = -1 - iota
+	Enter
+	Backspace
+)
+
+// Key identifies a single keystroke: a rune -- printable, or one of the
+// special values above -- together with any modifiers held down with it.
+type Key struct {
+	Rune rune
+	Mod  Mod
+}
+
+// DefaultBinding is the sentinel key used as a map key in keyBindings to
+// mean "whatever doesn't have a more specific binding in this mode".
+var DefaultBinding = Key{}
+
+// BuiltinFn is a named key-binding handler, as installed into keyBindings.
+type BuiltinFn struct {
+	name string
+	fn   func(*Editor)
+}
+
+// Call invokes the handler.
+func (b BuiltinFn) Call(ed *Editor) {
+	b.fn(ed)
+}
+
+// keyBindings maps each mode to the keys bound in it; each per-mode map
+// also typically has a DefaultBinding entry as a catch-all. Individual
+// files populate their own mode(s) from init(), merging into (rather than
+// replacing) a mode's map when more than one file contributes to it.
+var keyBindings = map[bufferMode]map[Key]BuiltinFn{
+	modeInsert: {},
+}
+
+func (k Key) String() string {
+	s := ""
+	if k.Mod&Ctrl != 0 {
+		s += "Ctrl-"
+	}
+	if k.Mod&Alt != 0 {
+		s += "Alt-"
+	}
+	switch k.Rune {
+	case Escape:
+		s += "Escape"
+	case Enter:
+		s += "Enter"
+	case Backspace:
+		s += "Backspace"
+	default:
+		s += string(k.Rune)
+	}
+	return s
+}
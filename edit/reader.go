@@ -0,0 +1,185 @@
+package edit
+
+import (
+	"bufio"
+	"os"
+)
+
+// Pos is a cursor position as reported by a terminal in response to a
+// Cursor Position Report query (\033[6n): 1-based row and column.
+type Pos struct {
+	Line, Col int
+}
+
+// invalidPos is the zero value of ReaderOutput.CPR, used to tell "this
+// isn't a CPR reply" apart from an actual (1, 1) position.
+var invalidPos = Pos{}
+
+// ReaderOutput is one event read off the terminal. Exactly one of Key,
+// CPR and Paste is meaningful, except when Err is set, in which case
+// none of them is.
+type ReaderOutput struct {
+	Key   Key
+	CPR   Pos
+	Paste string
+	Err   error
+}
+
+const (
+	pasteBeginSeq = "[200~"
+	pasteEndSeq   = "\033[201~"
+)
+
+// Reader turns raw bytes read from a terminal into a stream of
+// ReaderOutputs, reassembling multi-byte escape sequences -- including
+// the \033[200~ ... \033[201~ bracketed-paste wrapper, which it buffers
+// up internally and delivers as a single Paste event rather than as the
+// flood of keystrokes that make up the pasted text.
+type Reader struct {
+	file *os.File
+	ch   chan ReaderOutput
+	quit chan struct{}
+}
+
+// NewReader returns a Reader that reads from file. Call Run to start it
+// and Chan to receive what it reads.
+func NewReader(file *os.File) *Reader {
+	return &Reader{file, make(chan ReaderOutput), make(chan struct{})}
+}
+
+// Chan returns the channel on which the reader delivers events.
+func (rd *Reader) Chan() <-chan ReaderOutput {
+	return rd.ch
+}
+
+// Quit makes Run return at the next opportunity.
+func (rd *Reader) Quit() {
+	close(rd.quit)
+}
+
+// Run reads from rd's file until Quit is called or a read error occurs,
+// sending one ReaderOutput to rd.Chan() per event.
+func (rd *Reader) Run() {
+	r := bufio.NewReader(rd.file)
+	for {
+		select {
+		case <-rd.quit:
+			return
+		default:
+		}
+		out := readOne(r)
+		select {
+		case rd.ch <- out:
+		case <-rd.quit:
+			return
+		}
+		if out.Err != nil {
+			return
+		}
+	}
+}
+
+// readOne reads and returns a single event: a key, a CPR, a paste, or an
+// error. It is a free function, rather than a method on Reader, so the
+// sentinel-buffering logic can be unit tested by feeding it a
+// bufio.Reader over an in-memory byte sequence, without a real terminal.
+func readOne(r *bufio.Reader) ReaderOutput {
+	b, err := r.ReadByte()
+	if err != nil {
+		return ReaderOutput{Err: err}
+	}
+	if b == '\033' {
+		return readEscape(r)
+	}
+	return ReaderOutput{Key: readControlOrRune(r, b)}
+}
+
+// readEscape is called after a lone \033 byte has been consumed off r. It
+// recognizes the bracketed-paste begin sentinel and, failing that, falls
+// back to treating the escape as a lone Escape key (the case this reader
+// doesn't otherwise need to distinguish, such as cursor keys and CPR
+// replies, is intentionally out of scope here).
+func readEscape(r *bufio.Reader) ReaderOutput {
+	if consumeSeq(r, pasteBeginSeq) {
+		return ReaderOutput{Paste: readPaste(r)}
+	}
+	return ReaderOutput{Key: Key{Escape, 0}}
+}
+
+// readPaste consumes and returns everything up to (but not including) the
+// \033[201~ end sentinel, which it also consumes. If the input ends
+// before the end sentinel is seen, it returns what it has read so far.
+func readPaste(r *bufio.Reader) string {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return string(buf)
+		}
+		if b == pasteEndSeq[0] && consumeSeq(r, pasteEndSeq[1:]) {
+			return string(buf)
+		}
+		buf = append(buf, b)
+	}
+}
+
+// consumeSeq reports whether the next len(seq) bytes available from r are
+// exactly seq, consuming them if so and leaving r untouched if not.
+func consumeSeq(r *bufio.Reader, seq string) bool {
+	peeked, err := r.Peek(len(seq))
+	if err != nil || string(peeked) != seq {
+		return false
+	}
+	r.Discard(len(seq))
+	return true
+}
+
+// readControlOrRune turns a non-escape byte b into a Key: the C0 control
+// codes elvish cares about (Ctrl-A through Ctrl-Z, Enter, Backspace) are
+// translated to their named or Ctrl-modified form; anything else is
+// decoded as a (possibly multi-byte) rune.
+func readControlOrRune(r *bufio.Reader, b byte) Key {
+	switch {
+	case b == '\r' || b == '\n':
+		return Key{Enter, 0}
+	case b == 0x7f:
+		return Key{Backspace, 0}
+	case 1 <= b && b <= 26:
+		return Key{rune('A' + b - 1), Ctrl}
+	}
+	return Key{decodeRune(r, b), 0}
+}
+
+// decodeRune decodes the UTF-8 rune starting with lead byte b, reading
+// its continuation bytes (if any) from r.
+func decodeRune(r *bufio.Reader, b byte) rune {
+	var n int
+	switch {
+	case b&0x80 == 0x00:
+		return rune(b)
+	case b&0xe0 == 0xc0:
+		n = 1
+	case b&0xf0 == 0xe0:
+		n = 2
+	case b&0xf8 == 0xf0:
+		n = 3
+	default:
+		// Not a valid UTF-8 lead byte; surface it verbatim rather than
+		// losing it.
+		return rune(b)
+	}
+	buf := make([]byte, 1, 1+n)
+	buf[0] = b
+	for i := 0; i < n; i++ {
+		cb, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		buf = append(buf, cb)
+	}
+	runes := []rune(string(buf))
+	if len(runes) == 0 {
+		return rune(b)
+	}
+	return runes[0]
+}
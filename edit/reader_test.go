@@ -0,0 +1,59 @@
+package edit
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadOnePaste(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\033[200~echo hi\nls\033[201~"))
+	out := readOne(r)
+	if out.Err != nil {
+		t.Fatalf("unexpected error: %v", out.Err)
+	}
+	if out.Paste != "echo hi\nls" {
+		t.Fatalf("Paste = %q, want %q", out.Paste, "echo hi\nls")
+	}
+	if out.Key != (Key{}) {
+		t.Fatalf("Key = %+v, want zero value", out.Key)
+	}
+}
+
+func TestReadOnePasteThenMoreInput(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\033[200~ab\033[201~cd"))
+	out := readOne(r)
+	if out.Paste != "ab" {
+		t.Fatalf("Paste = %q, want %q", out.Paste, "ab")
+	}
+	// The bytes after the end sentinel are read as ordinary keys, not
+	// folded into the paste.
+	out = readOne(r)
+	if out.Key.Rune != 'c' {
+		t.Fatalf("Key = %+v, want 'c'", out.Key)
+	}
+}
+
+func TestReadOneRegularKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a"))
+	out := readOne(r)
+	if out.Key.Rune != 'a' || out.Key.Mod != 0 {
+		t.Fatalf("Key = %+v, want {'a', 0}", out.Key)
+	}
+}
+
+func TestReadOneCtrlKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x12")) // Ctrl-R
+	out := readOne(r)
+	if out.Key != (Key{'R', Ctrl}) {
+		t.Fatalf("Key = %+v, want Ctrl-R", out.Key)
+	}
+}
+
+func TestReadOneLoneEscape(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\033"))
+	out := readOne(r)
+	if out.Key != (Key{Escape, 0}) {
+		t.Fatalf("Key = %+v, want Escape", out.Key)
+	}
+}
@@ -0,0 +1,66 @@
+package edit
+
+import (
+	"strings"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// Runner abstracts the language the line editor is editing and executing.
+// Editor depends only on this interface for the editing loop (syntax
+// highlighting, compiler-error reporting and name lookup for completion),
+// which lets the same editor be reused as a library to build REPLs for
+// languages other than elvish.
+type Runner interface {
+	// Parse parses src into a syntax tree used for tokenizing and
+	// highlighting. A non-nil error does not preclude a partial, non-nil
+	// Node; implementations may return both so that highlighting can still
+	// proceed around the error.
+	Parse(src string) (parse.Node, error)
+	// Compile compiles src (already parsed into n) under name, returning a
+	// *errutil.ContextualError (or any error) if compilation fails. It is
+	// used purely for live error highlighting; Editor never asks a Runner
+	// to execute anything.
+	Compile(name, src string, n parse.Node) error
+	// Names returns the function and variable names currently visible,
+	// used to decide whether a command head is "good" for highlighting and
+	// to drive completion.
+	Names() (fns, vars []string)
+}
+
+// elvishRunner is the default Runner, backed by the elvish evaluator.
+type elvishRunner struct {
+	ev *eval.Evaler
+}
+
+// NewElvishRunner wraps ev as a Runner, preserving elvish's existing parse,
+// compile and name-resolution behavior.
+func NewElvishRunner(ev *eval.Evaler) Runner {
+	return &elvishRunner{ev}
+}
+
+func (r *elvishRunner) Parse(src string) (parse.Node, error) {
+	return parse.Parse(src)
+}
+
+func (r *elvishRunner) Compile(name, src string, n parse.Node) error {
+	_, err := r.ev.Compile(name, src, n)
+	return err
+}
+
+func (r *elvishRunner) Names() (fns, vars []string) {
+	add := func(ns map[string]eval.Variable) {
+		for name := range ns {
+			switch {
+			case strings.HasPrefix(name, eval.FnPrefix):
+				fns = append(fns, name[len(eval.FnPrefix):])
+			default:
+				vars = append(vars, name)
+			}
+		}
+	}
+	add(r.ev.Builtin.Names)
+	add(r.ev.Global.Names)
+	return fns, vars
+}
@@ -0,0 +1,301 @@
+package edit
+
+import "unicode"
+
+// normalState holds the pending-operator + count state machine for
+// modeNormal. A key sequence like "d3w" is assembled across key presses:
+// the operator is recorded first, then any count digits, then the motion
+// that completes the command.
+type normalState struct {
+	count         int  // accumulated count, 0 means "no count given"
+	operator      rune // pending operator ('d', 'c', 'y'), 0 if none
+	register      rune // register named with `"` before the operator/motion, 0 for default; -1 while awaiting the register letter
+	pendingMotion rune // 'f' or 't' awaiting its target character
+}
+
+func (n *normalState) reset() {
+	*n = normalState{}
+}
+
+// effectiveCount returns the count to use, defaulting to 1.
+func (n *normalState) effectiveCount() int {
+	if n.count == 0 {
+		return 1
+	}
+	return n.count
+}
+
+// registers implements the named-register subsystem: the default register
+// `""`, the numbered registers `"0`-`"9`, and the lettered registers
+// `"a`-`"z` (with uppercase appending rather than replacing).
+type registers struct {
+	unnamed  string
+	numbered [10]string
+	lettered [26]string
+}
+
+// get returns the contents of the register named by r (as typed after a
+// `"`, or 0 for the unnamed register).
+func (rs *registers) get(r rune) string {
+	switch {
+	case r == 0:
+		return rs.unnamed
+	case r >= '0' && r <= '9':
+		return rs.numbered[r-'0']
+	case r >= 'a' && r <= 'z':
+		return rs.lettered[r-'a']
+	case r >= 'A' && r <= 'Z':
+		return rs.lettered[r-'A']
+	}
+	return ""
+}
+
+// set writes text into the register named by r, and always updates the
+// unnamed register and the numbered-register ring to match vim's behavior.
+func (rs *registers) set(r rune, text string, isDelete bool) {
+	rs.unnamed = text
+	switch {
+	case r >= 'a' && r <= 'z':
+		rs.lettered[r-'a'] = text
+	case r >= 'A' && r <= 'Z':
+		rs.lettered[r-'A'] += text
+	}
+	if isDelete {
+		copy(rs.numbered[1:], rs.numbered[:9])
+		rs.numbered[1] = text
+	} else {
+		rs.numbered[0] = text
+	}
+}
+
+// motion finds the position dot moves to for a single-letter (or f/t style)
+// motion applied to line, starting at dot. ok is false for unrecognized
+// motions.
+func motion(line string, dot int, m rune, arg rune) (newDot int, ok bool) {
+	switch m {
+	case '0':
+		return 0, true
+	case '$':
+		return len(line), true
+	case 'w':
+		return motionWordForward(line, dot), true
+	case 'b':
+		return motionWordBackward(line, dot), true
+	case 'e':
+		return motionWordEnd(line, dot), true
+	case 'f':
+		if i := indexRuneFrom(line, dot+1, arg); i >= 0 {
+			return i, true
+		}
+		return dot, false
+	case 't':
+		if i := indexRuneFrom(line, dot+1, arg); i >= 0 {
+			return i - 1, true
+		}
+		return dot, false
+	}
+	return dot, false
+}
+
+func indexRuneFrom(s string, from int, r rune) int {
+	for i := from; i < len(s); i++ {
+		if rune(s[i]) == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func motionWordForward(line string, dot int) int {
+	i := dot
+	n := len(line)
+	for i < n && !unicode.IsSpace(rune(line[i])) {
+		i++
+	}
+	for i < n && unicode.IsSpace(rune(line[i])) {
+		i++
+	}
+	return i
+}
+
+func motionWordBackward(line string, dot int) int {
+	i := dot
+	for i > 0 && unicode.IsSpace(rune(line[i-1])) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(rune(line[i-1])) {
+		i--
+	}
+	return i
+}
+
+func motionWordEnd(line string, dot int) int {
+	n := len(line)
+	if n == 0 {
+		return dot
+	}
+	i := dot + 1
+	for i < n && unicode.IsSpace(rune(line[i])) {
+		i++
+	}
+	for i < n-1 && !unicode.IsSpace(rune(line[i+1])) {
+		i++
+	}
+	if i >= n {
+		i = n - 1
+	}
+	return i
+}
+
+// applyOperator runs operator op over [dot, end) of ed.line, writing the
+// affected text to the given register (0 for unnamed) and leaving the
+// editor in the mode the operator implies.
+func applyOperator(ed *Editor, op rune, reg rune, dot, end int) {
+	if end < dot {
+		dot, end = end, dot
+	}
+	text := ed.line[dot:end]
+	switch op {
+	case 'd':
+		ed.registers.set(reg, text, true)
+		ed.line = ed.line[:dot] + ed.line[end:]
+		ed.dot = dot
+	case 'c':
+		ed.registers.set(reg, text, true)
+		ed.line = ed.line[:dot] + ed.line[end:]
+		ed.dot = dot
+		ed.setMode(modeInsert)
+	case 'y':
+		ed.registers.set(reg, text, false)
+		ed.dot = dot
+	}
+}
+
+func enterNormalMode(ed *Editor) {
+	ed.normal.reset()
+	ed.setMode(modeNormal)
+}
+
+func normalInsert(ed *Editor)      { ed.setMode(modeInsert) }
+func normalAppend(ed *Editor)      { ed.dot = min(ed.dot+1, len(ed.line)); ed.setMode(modeInsert) }
+func normalAppendEnd(ed *Editor)   { ed.dot = len(ed.line); ed.setMode(modeInsert) }
+func normalInsertStart(ed *Editor) { ed.dot = 0; ed.setMode(modeInsert) }
+
+func normalPaste(ed *Editor) {
+	text := ed.registers.get(ed.normal.register)
+	// p pastes after the character under the cursor, unlike P (and insert
+	// mode), which paste exactly at the cursor.
+	ed.dot = min(ed.dot+1, len(ed.line))
+	ed.insertAtDot(text)
+	ed.normal.reset()
+}
+
+func normalPasteBefore(ed *Editor) {
+	text := ed.registers.get(ed.normal.register)
+	ed.line = ed.line[:ed.dot] + text + ed.line[ed.dot:]
+	ed.normal.reset()
+}
+
+// normalKey is the DefaultBinding handler for modeNormal: it feeds one key
+// at a time into the pending count/register/operator/motion state machine.
+func normalKey(ed *Editor) {
+	k := ed.lastKey
+	n := &ed.normal
+
+	if n.pendingMotion != 0 {
+		// f/t take whatever the very next keystroke is as their target,
+		// even if it would otherwise be bound to an operator, a register
+		// name, a count digit, or another motion. This must be checked
+		// before any of that dispatch, not as a fallback once everything
+		// else has failed to match.
+		m := n.pendingMotion
+		n.pendingMotion = 0
+		runMotion(ed, m, k.Rune)
+		return
+	}
+
+	if k.Mod == 0 && k.Rune == '"' {
+		// The next key names a register; handled by reading one more key
+		// via nextAction/reprocessKey is avoided here for simplicity: vim
+		// itself treats `"` as needing exactly one more keystroke, so we
+		// stash a sentinel and consume it on the next call.
+		n.register = -1
+		return
+	}
+	if n.register == -1 && k.Mod == 0 {
+		n.register = k.Rune
+		return
+	}
+	if k.Mod == 0 && k.Rune >= '1' && k.Rune <= '9' {
+		n.count = n.count*10 + int(k.Rune-'0')
+		return
+	}
+	if k.Mod == 0 && k.Rune == '0' && n.count != 0 {
+		n.count *= 10
+		return
+	}
+
+	switch {
+	case k.Mod == 0 && (k.Rune == 'd' || k.Rune == 'c' || k.Rune == 'y'):
+		if n.operator == k.Rune {
+			// dd, cc, yy: operate on the whole line.
+			applyOperator(ed, n.operator, n.register, 0, len(ed.line))
+			n.reset()
+			return
+		}
+		n.operator = k.Rune
+		return
+	case k.Mod == 0 && k.Rune == 'i':
+		normalInsert(ed)
+		n.reset()
+	case k.Mod == 0 && k.Rune == 'a':
+		normalAppend(ed)
+		n.reset()
+	case k.Mod == 0 && k.Rune == 'A':
+		normalAppendEnd(ed)
+		n.reset()
+	case k.Mod == 0 && k.Rune == 'I':
+		normalInsertStart(ed)
+		n.reset()
+	case k.Mod == 0 && k.Rune == 'p':
+		normalPaste(ed)
+	case k.Mod == 0 && k.Rune == 'P':
+		normalPasteBefore(ed)
+	case k.Mod == 0 && (k.Rune == 'w' || k.Rune == 'b' || k.Rune == 'e' || k.Rune == '0' || k.Rune == '$'):
+		runMotion(ed, k.Rune, 0)
+	case k.Mod == 0 && (k.Rune == 'f' || k.Rune == 't'):
+		// f/t need one more key, the target character; stash it and
+		// consume the next keystroke as the motion's argument (handled at
+		// the top of this function on the next call).
+		n.pendingMotion = k.Rune
+	default:
+		ed.pushTip("Unbound: " + k.String())
+		n.reset()
+	}
+}
+
+func runMotion(ed *Editor, m rune, arg rune) {
+	n := &ed.normal
+	count := n.effectiveCount()
+	dot := ed.dot
+	for i := 0; i < count; i++ {
+		newDot, ok := motion(ed.line, dot, m, arg)
+		if !ok {
+			break
+		}
+		dot = newDot
+	}
+	if n.operator != 0 {
+		applyOperator(ed, n.operator, n.register, ed.dot, dot)
+	} else {
+		ed.dot = dot
+	}
+	n.reset()
+}
+
+func init() {
+	keyBindings[modeInsert][Key{Escape, 0}] = BuiltinFn{"enter-normal-mode", enterNormalMode}
+	keyBindings[modeNormal] = map[Key]BuiltinFn{
+		DefaultBinding: BuiltinFn{"normal-default", normalKey},
+	}
+}
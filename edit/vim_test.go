@@ -0,0 +1,123 @@
+package edit
+
+import "testing"
+
+func TestRegistersUnnamedAndNumbered(t *testing.T) {
+	var rs registers
+	rs.set(0, "first", true)
+	rs.set(0, "second", true)
+	if rs.get(0) != "second" {
+		t.Fatalf("unnamed register = %q, want %q", rs.get(0), "second")
+	}
+	if rs.get('1') != "second" {
+		t.Fatalf("\"1 register = %q, want %q", rs.get('1'), "second")
+	}
+	if rs.get('2') != "first" {
+		t.Fatalf("\"2 register = %q, want %q", rs.get('2'), "first")
+	}
+}
+
+func TestRegistersYankGoesToRegister0NotTheRing(t *testing.T) {
+	var rs registers
+	rs.set(0, "deleted", true)
+	rs.set(0, "yanked", false)
+	if rs.get('0') != "yanked" {
+		t.Fatalf("\"0 register = %q, want %q", rs.get('0'), "yanked")
+	}
+	if rs.get('1') != "deleted" {
+		t.Fatalf("\"1 register = %q, want %q", rs.get('1'), "deleted")
+	}
+}
+
+func TestRegistersLetteredAppend(t *testing.T) {
+	var rs registers
+	rs.set('a', "foo", true)
+	rs.set('A', "bar", true)
+	if rs.get('a') != "foobar" {
+		t.Fatalf("\"a register = %q, want %q", rs.get('a'), "foobar")
+	}
+}
+
+func TestMotionWordForward(t *testing.T) {
+	line := "foo bar baz"
+	if got := motionWordForward(line, 0); got != 4 {
+		t.Fatalf("motionWordForward(0) = %d, want 4", got)
+	}
+	if got := motionWordForward(line, 4); got != 8 {
+		t.Fatalf("motionWordForward(4) = %d, want 8", got)
+	}
+}
+
+func TestMotionWordBackward(t *testing.T) {
+	line := "foo bar baz"
+	if got := motionWordBackward(line, 11); got != 8 {
+		t.Fatalf("motionWordBackward(11) = %d, want 8", got)
+	}
+	if got := motionWordBackward(line, 8); got != 4 {
+		t.Fatalf("motionWordBackward(8) = %d, want 4", got)
+	}
+}
+
+func TestMotionWordEnd(t *testing.T) {
+	line := "foo bar baz"
+	if got := motionWordEnd(line, 0); got != 2 {
+		t.Fatalf("motionWordEnd(0) = %d, want 2", got)
+	}
+}
+
+func TestMotionWordEndOnEmptyLine(t *testing.T) {
+	if got := motionWordEnd("", 0); got != 0 {
+		t.Fatalf("motionWordEnd(\"\", 0) = %d, want 0", got)
+	}
+}
+
+func TestNormalPasteInsertsAfterDot(t *testing.T) {
+	ed := &Editor{editorState: editorState{line: "abc", dot: 0, mode: modeNormal}}
+	ed.registers.set(0, "X", true)
+	normalPaste(ed)
+	if ed.line != "aXbc" {
+		t.Fatalf("ed.line = %q, want %q", ed.line, "aXbc")
+	}
+}
+
+func TestNormalPasteBeforeInsertsAtDot(t *testing.T) {
+	ed := &Editor{editorState: editorState{line: "abc", dot: 0, mode: modeNormal}}
+	ed.registers.set(0, "X", true)
+	normalPasteBefore(ed)
+	if ed.line != "Xabc" {
+		t.Fatalf("ed.line = %q, want %q", ed.line, "Xabc")
+	}
+}
+
+func TestMotionFindChar(t *testing.T) {
+	line := "foo bar baz"
+	dot, ok := motion(line, 0, 'f', 'b')
+	if !ok || dot != 4 {
+		t.Fatalf("motion f b from 0 = (%d, %v), want (4, true)", dot, ok)
+	}
+	dot, ok = motion(line, 0, 't', 'b')
+	if !ok || dot != 3 {
+		t.Fatalf("motion t b from 0 = (%d, %v), want (3, true)", dot, ok)
+	}
+}
+
+func TestNormalKeyFindTargetNotStolenByBoundKeys(t *testing.T) {
+	ed := &Editor{}
+	ed.editorState = editorState{line: "foo bar baz", dot: 0, mode: modeNormal}
+	ed.lastKey = Key{'f', 0}
+	normalKey(ed)
+	if ed.normal.pendingMotion != 'f' {
+		t.Fatalf("expected pendingMotion to be 'f' after pressing f, got %q", ed.normal.pendingMotion)
+	}
+
+	// 'b' is itself bound to the "move word backward" motion in normal
+	// mode, but here it must be consumed as f's target character instead.
+	ed.lastKey = Key{'b', 0}
+	normalKey(ed)
+	if ed.normal.pendingMotion != 0 {
+		t.Fatalf("pendingMotion should be cleared after being consumed, got %q", ed.normal.pendingMotion)
+	}
+	if ed.dot != 4 {
+		t.Fatalf("dot = %d, want 4 (the first 'b' in %q)", ed.dot, ed.line)
+	}
+}